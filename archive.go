@@ -0,0 +1,52 @@
+package tatar
+
+import (
+	"bytes"
+	"io"
+)
+
+// Archive is the contract shared by Tar and Zip. Their ForEach callbacks
+// stay format-specific (*tar.Header vs *zip.FileHeader), so it is
+// deliberately not part of this interface
+type Archive interface {
+	ToDirectory(path string) error
+	ToFile(path string) (int64, error)
+	Add(fsPath, archivePath string) error
+}
+
+// Add appends the file, directory or symlink at fsPath to the archive under
+// archivePath, rebuilding Data in place
+func (t *Tar) Add(fsPath, archivePath string) error {
+	buf := &bytes.Buffer{}
+	writer, err := NewStreamWriter(buf, NO_COMPRESSION)
+	if err != nil {
+		return err
+	}
+	reader, err := NewStreamReader(bytes.NewReader(t.Data), NO_COMPRESSION)
+	if err != nil {
+		return err
+	}
+	for {
+		hdr, r, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := writer.AddFile(hdr, r); err != nil {
+			return err
+		}
+	}
+	if err := writer.AddPath(fsPath, archivePath); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	t.Data = buf.Bytes()
+	return nil
+}
+
+var _ Archive = (*Tar)(nil)
+var _ Archive = (*Zip)(nil)
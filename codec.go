@@ -0,0 +1,198 @@
+package tatar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Codec implements (de)compression for one CompressionType. Register custom
+// codecs with RegisterCodec to support algorithms beyond the built-in ones
+// without forking this package
+type Codec interface {
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	// Magic returns the byte sequence that identifies the format at the
+	// start of a stream, used by the AUTO compression sniffer
+	Magic() []byte
+	// Extensions returns the file extensions (including the leading dot)
+	// recognized by GuessCompression, e.g. []string{".gz", ".gzip"}
+	Extensions() []string
+}
+
+// LeveledCodec is implemented by codecs that support a numeric compression
+// level. Tar.CompressionLevel is passed through to NewWriterLevel
+type LeveledCodec interface {
+	Codec
+	NewWriterLevel(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+var codecs = map[CompressionType]Codec{}
+
+// RegisterCodec makes a Codec available for CompressionType c, overriding
+// any codec previously registered for it
+func RegisterCodec(c CompressionType, codec Codec) {
+	codecs[c] = codec
+}
+
+func init() {
+	RegisterCodec(GZIP, gzipCodec{})
+	RegisterCodec(BZIP2, bzip2Codec{})
+	RegisterCodec(LZMA, lzmaCodec{})
+	RegisterCodec(ZSTD, zstdCodec{})
+	RegisterCodec(LZ4, lz4Codec{})
+}
+
+// nopCloser adapts an io.Reader without a Close method to io.ReadCloser
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// gzipCodec is the built-in Codec for GZIP
+type gzipCodec struct{}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+func (gzipCodec) NewWriterLevel(w io.Writer, level int) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+func (gzipCodec) Magic() []byte                                { return []byte{0x1F, 0x8B, 0x08} }
+func (gzipCodec) Extensions() []string                         { return []string{".gz", ".gzip"} }
+
+// bzip2Codec is the built-in Codec for BZIP2
+type bzip2Codec struct{}
+
+func (bzip2Codec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return bzip2.NewWriter(w, nil)
+}
+func (bzip2Codec) NewWriterLevel(w io.Writer, level int) (io.WriteCloser, error) {
+	return bzip2.NewWriter(w, &bzip2.WriterConfig{Level: level})
+}
+func (bzip2Codec) NewReader(r io.Reader) (io.ReadCloser, error) { return bzip2.NewReader(r, nil) }
+func (bzip2Codec) Magic() []byte                                { return []byte{0x42, 0x5A, 0x68} }
+func (bzip2Codec) Extensions() []string                         { return []string{".bz2", ".bzip2"} }
+
+// lzmaCodec is the built-in Codec for LZMA
+type lzmaCodec struct{}
+
+func (lzmaCodec) NewWriter(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }
+func (lzmaCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{xr}, nil
+}
+func (lzmaCodec) Magic() []byte { return []byte{0xFD, 0x37, 0x7A, 0x58, 0x5A, 0x00} }
+func (lzmaCodec) Extensions() []string {
+	return []string{".xz", ".lzma"}
+}
+
+// zstdCodec is the built-in Codec for ZSTD
+type zstdCodec struct{}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+func (zstdCodec) NewWriterLevel(w io.Writer, level int) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+}
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+func (zstdCodec) Magic() []byte        { return []byte{0x28, 0xB5, 0x2F, 0xFD} }
+func (zstdCodec) Extensions() []string { return []string{".zst", ".zstd"} }
+
+// lz4Codec is the built-in Codec for LZ4
+type lz4Codec struct{}
+
+func (lz4Codec) NewWriter(w io.Writer) (io.WriteCloser, error) { return lz4.NewWriter(w), nil }
+func (lz4Codec) NewReader(r io.Reader) (io.ReadCloser, error)  { return nopCloser{lz4.NewReader(r)}, nil }
+func (lz4Codec) Magic() []byte                                 { return []byte{0x04, 0x22, 0x4D, 0x18} }
+func (lz4Codec) Extensions() []string                          { return []string{".lz4"} }
+
+// newCompressor wraps out with the codec registered for c, honoring level if
+// the codec implements LeveledCodec. The returned closer is nil for
+// NO_COMPRESSION and must otherwise be closed once writing is done
+func newCompressor(out io.Writer, c CompressionType, level int) (io.Writer, io.Closer, error) {
+	if c == NO_COMPRESSION {
+		return out, nil, nil
+	}
+	codec, ok := codecs[c]
+	if !ok {
+		return nil, nil, errors.New("unknown compression")
+	}
+	if level != 0 {
+		if leveled, ok := codec.(LeveledCodec); ok {
+			w, err := leveled.NewWriterLevel(out, level)
+			if err != nil {
+				return nil, nil, err
+			}
+			return w, w, nil
+		}
+	}
+	w, err := codec.NewWriter(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, w, nil
+}
+
+// newDecompressor wraps in with the codec registered for c. The returned
+// closer is nil for NO_COMPRESSION
+func newDecompressor(in io.Reader, c CompressionType) (io.Reader, io.Closer, error) {
+	if c == NO_COMPRESSION {
+		return in, nil, nil
+	}
+	codec, ok := codecs[c]
+	if !ok {
+		return nil, nil, errors.New("unknown compression")
+	}
+	r, err := codec.NewReader(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, r, nil
+}
+
+// sniffCompression peeks at the head of br and returns the CompressionType
+// whose registered Codec's Magic matches, or NO_COMPRESSION if none match
+func sniffCompression(br *bufio.Reader) (CompressionType, error) {
+	head, err := br.Peek(6)
+	if err != nil && err != io.EOF {
+		return NO_COMPRESSION, err
+	}
+	for ctype, codec := range codecs {
+		if bytes.HasPrefix(head, codec.Magic()) {
+			return ctype, nil
+		}
+	}
+	return NO_COMPRESSION, nil
+}
+
+// GuessCompression guesses the compression type by the registered codecs'
+// file extensions
+func GuessCompression(name string) CompressionType {
+	ext := filepath.Ext(name)
+	for ctype, codec := range codecs {
+		for _, candidate := range codec.Extensions() {
+			if strings.EqualFold(ext, candidate) {
+				return ctype
+			}
+		}
+	}
+	return NO_COMPRESSION
+}
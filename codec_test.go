@@ -0,0 +1,127 @@
+package tatar
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZstdAndLz4RoundTrip(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	archive.Compression = ZSTD
+	zstdData, err := archive.ToData()
+	assert.Nil(t, err)
+	assert.True(t, len(zstdData) > 0)
+
+	restored, err := NewFromData(zstdData, ZSTD)
+	assert.Nil(t, err)
+	assert.Equal(t, archive.Data, restored.Data)
+
+	archive.Compression = LZ4
+	lz4Data, err := archive.ToData()
+	assert.Nil(t, err)
+	assert.True(t, len(lz4Data) > 0)
+
+	restored, err = NewFromData(lz4Data, LZ4)
+	assert.Nil(t, err)
+	assert.Equal(t, archive.Data, restored.Data)
+}
+
+func TestZstdCompressionLevelRoundTrip(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	archive.Compression = ZSTD
+	archive.CompressionLevel = 19
+	zstdData, err := archive.ToData()
+	assert.Nil(t, err)
+	assert.True(t, len(zstdData) > 0)
+
+	restored, err := NewFromData(zstdData, ZSTD)
+	assert.Nil(t, err)
+	assert.Equal(t, archive.Data, restored.Data)
+}
+
+func TestNewFromReaderDetectsZstdAndLz4(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	archive.Compression = ZSTD
+	zstdData, err := archive.ToData()
+	assert.Nil(t, err)
+	restored, err := NewFromReader(bytes.NewReader(zstdData))
+	assert.Nil(t, err)
+	assert.Equal(t, ZSTD, restored.Compression)
+
+	archive.Compression = LZ4
+	lz4Data, err := archive.ToData()
+	assert.Nil(t, err)
+	restored, err = NewFromReader(bytes.NewReader(lz4Data))
+	assert.Nil(t, err)
+	assert.Equal(t, LZ4, restored.Compression)
+}
+
+func TestGuessCompressionZstdAndLz4(t *testing.T) {
+	assert.Equal(t, ZSTD, GuessCompression("archive.zst"))
+	assert.Equal(t, ZSTD, GuessCompression("archive.ZSTD"))
+	assert.Equal(t, LZ4, GuessCompression("archive.lz4"))
+}
+
+func TestToFileZstdAndLz4(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	testFileZstd := filepath.Join(targetDir + "-zstd-source.tar.zst")
+	testFileLz4 := filepath.Join(targetDir + "-lz4-source.tar.lz4")
+
+	archive.Compression = ZSTD
+	_, err = archive.ToFile(testFileZstd)
+	assert.Nil(t, err)
+	restored, err := NewFromFile(testFileZstd)
+	assert.Nil(t, err)
+	extractDir := targetDir + "-zstd"
+	assert.Nil(t, restored.ToDirectory(extractDir))
+	assert.Nil(t, exec.Command("diff", "-r", testDir, extractDir).Run())
+
+	archive.Compression = LZ4
+	_, err = archive.ToFile(testFileLz4)
+	assert.Nil(t, err)
+	restored, err = NewFromFile(testFileLz4)
+	assert.Nil(t, err)
+	extractDir = targetDir + "-lz4"
+	assert.Nil(t, restored.ToDirectory(extractDir))
+	assert.Nil(t, exec.Command("diff", "-r", testDir, extractDir).Run())
+}
+
+func TestRegisterCodecOverride(t *testing.T) {
+	previous := codecs[GZIP]
+	defer RegisterCodec(GZIP, previous)
+
+	calls := 0
+	RegisterCodec(GZIP, countingGzipCodec{gzipCodec{}, &calls})
+
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+	archive.Compression = GZIP
+	_, err = archive.ToData()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// countingGzipCodec wraps gzipCodec to verify RegisterCodec actually swaps
+// out the codec used by Save/Load
+type countingGzipCodec struct {
+	gzipCodec
+	calls *int
+}
+
+func (c countingGzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	*c.calls++
+	return c.gzipCodec.NewWriter(w)
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package tatar
+
+import (
+	"archive/tar"
+	"syscall"
+)
+
+// mkdev composes a Linux dev_t the same way glibc's gnu_dev_makedev does
+func mkdev(major, minor uint32) int {
+	return int((major << 8) | (minor & 0xff) | ((minor &^ 0xff) << 12))
+}
+
+// mknod creates the device or FIFO node described by hdr at path
+func mknod(path string, hdr *tar.Header) error {
+	mode := uint32(hdr.Mode & 0777)
+	switch hdr.Typeflag {
+	case tar.TypeBlock:
+		mode |= syscall.S_IFBLK
+	case tar.TypeChar:
+		mode |= syscall.S_IFCHR
+	case tar.TypeFifo:
+		mode |= syscall.S_IFIFO
+	}
+	return syscall.Mknod(path, mode, mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor)))
+}
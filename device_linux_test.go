@@ -0,0 +1,29 @@
+//go:build linux
+
+package tatar
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractEntryWithOptionsFifoNode(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-options-fifo")
+	os.RemoveAll(dest)
+	assert.Nil(t, os.MkdirAll(dest, 0755))
+
+	hdr := &tar.Header{
+		Name:     "myfifo",
+		Typeflag: tar.TypeFifo,
+		Mode:     0644,
+	}
+	assert.Nil(t, extractEntryWithOptions(dest, hdr, nil, &TarOptions{NoLchown: true}))
+
+	info, err := os.Stat(filepath.Join(dest, "myfifo"))
+	assert.Nil(t, err)
+	assert.True(t, info.Mode()&os.ModeNamedPipe != 0)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tatar
+
+import (
+	"archive/tar"
+	"errors"
+)
+
+// mknod is not implemented outside of linux
+func mknod(path string, hdr *tar.Header) error {
+	return errors.New("tatar: device nodes are not supported on this platform")
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package tatar
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the device and inode number backing info, used to detect
+// hardlinks while walking a directory tree
+func inodeOf(info os.FileInfo) (dev uint64, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), st.Ino, true
+}
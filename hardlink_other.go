@@ -0,0 +1,11 @@
+//go:build !linux
+
+package tatar
+
+import "os"
+
+// inodeOf is a stub on platforms where syscall.Stat_t isn't available in
+// the expected shape; hardlinks are then simply archived as regular files
+func inodeOf(info os.FileInfo) (dev uint64, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,321 @@
+package tatar
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Owner is a uid/gid pair used to override ownership on extraction
+type Owner struct {
+	UID int
+	GID int
+}
+
+// TarOptions controls filtering, ownership and per-entry rewriting for the
+// options-aware variants of the archive/extraction functions. It is modeled
+// on Docker's archive package. A nil *TarOptions is equivalent to an empty
+// TarOptions
+type TarOptions struct {
+	// Includes, if non-empty, keeps only archive-relative paths matching
+	// at least one of these glob patterns
+	Includes []string
+	// Excludes drops archive-relative paths matching any of these glob
+	// patterns, even if they also match Includes
+	Excludes []string
+	// NoLchown disables restoring hdr.Uid/Gid via os.Lchown on extraction
+	NoLchown bool
+	// ChownOverride, if set, is used instead of hdr.Uid/Gid on extraction
+	ChownOverride *Owner
+	// Compression is used by NewFromDirectoryWithOptions to set the
+	// resulting Tar's Compression field
+	Compression CompressionType
+	// Transform is called for every entry before it is written; returning
+	// false skips the entry entirely
+	Transform func(*tar.Header) (*tar.Header, bool)
+}
+
+// matchAny reports whether name, or its base name, matches any of patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether archivePath matches any of opts' Excludes
+// patterns. Unlike a plain Includes mismatch, this is a positive statement
+// that the path (and, for a directory, everything under it) should be
+// dropped
+func excluded(opts *TarOptions, archivePath string) bool {
+	if opts == nil {
+		return false
+	}
+	return matchAny(opts.Excludes, archivePath)
+}
+
+// included reports whether archivePath passes opts' Includes/Excludes
+// filters. Note that a directory failing Includes does not mean its
+// descendants fail too, since Includes commonly selects a nested path
+// (e.g. "sub/data.txt") that doesn't match the parent directory itself
+func included(opts *TarOptions, archivePath string) bool {
+	if opts == nil {
+		return true
+	}
+	if excluded(opts, archivePath) {
+		return false
+	}
+	if len(opts.Includes) > 0 && !matchAny(opts.Includes, archivePath) {
+		return false
+	}
+	return true
+}
+
+// NewFromDirectoryWithOptions is NewFromDirectory with filtering, a rename
+// hook and an explicit result Compression, see TarOptions
+func NewFromDirectoryWithOptions(directory string, opts *TarOptions) (*Tar, error) {
+	buf := &bytes.Buffer{}
+	writer, err := NewStreamWriter(buf, NO_COMPRESSION)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.AddDirectoryWithOptions(directory, opts); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	res := &Tar{Data: buf.Bytes()}
+	if opts != nil {
+		res.Compression = opts.Compression
+	}
+	return res, nil
+}
+
+// AddDirectoryWithOptions is AddDirectory with filtering, a rename hook and
+// hardlink detection, see TarOptions
+func (sw *StreamWriter) AddDirectoryWithOptions(dir string, opts *TarOptions) error {
+	dir, _ = filepath.Abs(dir)
+	seen := map[[2]uint64]string{}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Clean(dir) == filepath.Clean(path) {
+			return nil
+		}
+		archivePath := path[len(dir)+1:]
+		if excluded(opts, archivePath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !included(opts, archivePath) {
+			// Includes doesn't match this directory itself, but a nested
+			// path under it might still match, so keep walking into it
+			// without writing an entry for the directory
+			return nil
+		}
+		return sw.addPathWithOptions(path, archivePath, info, opts, seen)
+	})
+}
+
+func (sw *StreamWriter) addPathWithOptions(fsPath, archivePath string, info os.FileInfo, opts *TarOptions, seen map[[2]uint64]string) error {
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		l, err := os.Readlink(fsPath)
+		if err != nil {
+			return err
+		}
+		link = l
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = archivePath
+
+	isRegular := !info.IsDir() && info.Mode()&os.ModeSymlink == 0
+	if isRegular {
+		if dev, ino, ok := inodeOf(info); ok {
+			key := [2]uint64{dev, ino}
+			if original, seenBefore := seen[key]; seenBefore {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = original
+				hdr.Size = 0
+				isRegular = false
+			} else {
+				seen[key] = archivePath
+			}
+		}
+	}
+
+	if opts != nil && opts.Transform != nil {
+		newHdr, keep := opts.Transform(hdr)
+		if !keep {
+			return nil
+		}
+		hdr = newHdr
+	}
+
+	if !isRegular {
+		return sw.AddFile(hdr, nil)
+	}
+	f, err := os.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sw.AddFile(hdr, f)
+}
+
+// ToDirectoryWithOptions is ToDirectory with filtering, ownership handling
+// and a rename hook, see TarOptions
+func (t *Tar) ToDirectoryWithOptions(path string, opts *TarOptions) error {
+	reader, err := NewStreamReader(bytes.NewReader(t.Data), NO_COMPRESSION)
+	if err != nil {
+		return err
+	}
+	return reader.ExtractToWithOptions(path, opts)
+}
+
+// ExtractToWithOptions is ExtractTo with filtering, ownership handling and a
+// rename hook, see TarOptions
+func (sr *StreamReader) ExtractToWithOptions(dir string, opts *TarOptions) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var excludedDirs []string
+	for {
+		hdr, r, err := sr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if underAny(excludedDirs, hdr.Name) {
+			continue
+		}
+		if excluded(opts, hdr.Name) {
+			if hdr.FileInfo().IsDir() {
+				excludedDirs = append(excludedDirs, hdr.Name)
+			}
+			continue
+		}
+		if !included(opts, hdr.Name) {
+			// Includes doesn't match this directory itself, but a nested
+			// path under it might still match as its own tar entry, so
+			// don't drop the subtree the way a genuine Excludes match does
+			continue
+		}
+		if opts != nil && opts.Transform != nil {
+			newHdr, keep := opts.Transform(hdr)
+			if !keep {
+				continue
+			}
+			hdr = newHdr
+		}
+		if err := extractEntryWithOptions(dir, hdr, r, opts); err != nil {
+			return err
+		}
+	}
+}
+
+// underAny reports whether archivePath is at or below any of dirs, treating
+// each entry in dirs as an archive-relative directory prefix
+func underAny(dirs []string, archivePath string) bool {
+	for _, dir := range dirs {
+		if archivePath == dir || strings.HasPrefix(archivePath, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// extractEntryWithOptions is extractEntry extended with hardlink and device
+// node support plus ownership/mtime restoration
+func extractEntryWithOptions(root string, hdr *tar.Header, r io.Reader, opts *TarOptions) error {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	targetPath, err := secureJoin(rootAbs, hdr.Name)
+	if err != nil {
+		return err
+	}
+	// validateLinkname resolves Linkname relative to the entry's own
+	// directory, which only applies to symlinks. Hardlinks (tar.TypeLink)
+	// store an archive-root-relative Linkname, exactly like hdr.Name, and
+	// are validated root-relative below via secureJoin instead.
+	if hdr.Linkname != "" && hdr.Typeflag != tar.TypeLink {
+		if err := validateLinkname(rootAbs, targetPath, hdr.Linkname); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case hdr.FileInfo().IsDir():
+		if err := os.MkdirAll(targetPath, os.FileMode(hdr.Mode)); err != nil {
+			return err
+		}
+	case hdr.Typeflag == tar.TypeSymlink:
+		if err := os.Symlink(hdr.Linkname, targetPath); err != nil {
+			return err
+		}
+	case hdr.Typeflag == tar.TypeLink:
+		linkTarget, err := secureJoin(rootAbs, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(linkTarget, targetPath); err != nil {
+			return err
+		}
+	case hdr.Typeflag == tar.TypeBlock || hdr.Typeflag == tar.TypeChar || hdr.Typeflag == tar.TypeFifo:
+		if err := mknod(targetPath, hdr); err != nil {
+			return err
+		}
+	default:
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(targetPath)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Chmod(os.FileMode(hdr.Mode)); err != nil {
+			f.Close()
+			return err
+		}
+		f.Close()
+	}
+
+	if opts == nil || !opts.NoLchown {
+		uid, gid := hdr.Uid, hdr.Gid
+		if opts != nil && opts.ChownOverride != nil {
+			uid, gid = opts.ChownOverride.UID, opts.ChownOverride.GID
+		}
+		if err := os.Lchown(targetPath, uid, gid); err != nil {
+			return err
+		}
+	}
+	if hdr.Typeflag != tar.TypeSymlink {
+		if err := os.Chtimes(targetPath, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package tatar
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFromDirectoryWithOptionsExcludes(t *testing.T) {
+	archive, err := NewFromDirectoryWithOptions(testDir, &TarOptions{
+		Excludes: []string{"sub"},
+	})
+	assert.Nil(t, err)
+
+	reader := archive.GetReader()
+	names := []string{}
+	for {
+		hdr, err := reader.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	assert.Contains(t, names, "data1.txt")
+	assert.NotContains(t, names, "sub")
+	assert.NotContains(t, names, "sub/data2.txt")
+}
+
+func TestNewFromDirectoryWithOptionsIncludesNestedPath(t *testing.T) {
+	archive, err := NewFromDirectoryWithOptions(testDir, &TarOptions{
+		Includes: []string{"sub/data2.txt"},
+	})
+	assert.Nil(t, err)
+
+	reader := archive.GetReader()
+	names := []string{}
+	for {
+		hdr, err := reader.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	assert.Contains(t, names, "sub/data2.txt")
+	assert.NotContains(t, names, "data1.txt")
+}
+
+func TestNewFromDirectoryWithOptionsTransform(t *testing.T) {
+	archive, err := NewFromDirectoryWithOptions(testDir, &TarOptions{
+		Transform: func(hdr *tar.Header) (*tar.Header, bool) {
+			if hdr.Name == "data1.txt" {
+				return nil, false
+			}
+			hdr.Name = filepath.ToSlash(filepath.Join("renamed", hdr.Name))
+			return hdr, true
+		},
+	})
+	assert.Nil(t, err)
+
+	reader := archive.GetReader()
+	names := []string{}
+	for {
+		hdr, err := reader.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	assert.NotContains(t, names, "data1.txt")
+	assert.Contains(t, names, "renamed/sub/data2.txt")
+}
+
+func TestToDirectoryWithOptionsExcludesDropsDescendants(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	dest := filepath.Join(os.TempDir(), "tatar-test-options-excludes")
+	os.RemoveAll(dest)
+
+	err = archive.ToDirectoryWithOptions(dest, &TarOptions{Excludes: []string{"sub"}})
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(dest, "data1.txt"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(dest, "sub"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestToDirectoryWithOptionsIncludesNestedPath(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	dest := filepath.Join(os.TempDir(), "tatar-test-options-includes")
+	os.RemoveAll(dest)
+
+	err = archive.ToDirectoryWithOptions(dest, &TarOptions{Includes: []string{"sub/data2.txt"}})
+	assert.Nil(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "sub", "data2.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, data2, content)
+
+	_, err = os.Stat(filepath.Join(dest, "data1.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAddDirectoryWithOptionsHardlinkRoundtrip(t *testing.T) {
+	src := filepath.Join(os.TempDir(), "tatar-test-options-hardlink-src")
+	os.RemoveAll(src)
+	assert.Nil(t, os.MkdirAll(src, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "original.txt"), data1, 0644))
+	assert.Nil(t, os.Link(filepath.Join(src, "original.txt"), filepath.Join(src, "linked.txt")))
+
+	archive, err := NewFromDirectoryWithOptions(src, &TarOptions{})
+	assert.Nil(t, err)
+
+	dest := filepath.Join(os.TempDir(), "tatar-test-options-hardlink-dest")
+	os.RemoveAll(dest)
+	assert.Nil(t, archive.ToDirectoryWithOptions(dest, &TarOptions{}))
+
+	originalInfo, err := os.Stat(filepath.Join(dest, "original.txt"))
+	assert.Nil(t, err)
+	linkedInfo, err := os.Stat(filepath.Join(dest, "linked.txt"))
+	assert.Nil(t, err)
+	assert.True(t, os.SameFile(originalInfo, linkedInfo))
+}
+
+func TestToDirectoryWithOptionsChownOverride(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	dest := filepath.Join(os.TempDir(), "tatar-test-options-chown")
+	os.RemoveAll(dest)
+
+	err = archive.ToDirectoryWithOptions(dest, &TarOptions{
+		ChownOverride: &Owner{UID: os.Getuid(), GID: os.Getgid()},
+	})
+	assert.Nil(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "data1.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, data1, content)
+}
+
+func TestToDirectoryWithOptionsNoLchown(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	dest := filepath.Join(os.TempDir(), "tatar-test-options-nolchown")
+	os.RemoveAll(dest)
+
+	err = archive.ToDirectoryWithOptions(dest, &TarOptions{NoLchown: true})
+	assert.Nil(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "data1.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, data1, content)
+}
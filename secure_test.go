@@ -0,0 +1,96 @@
+package tatar
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildMaliciousTar(t *testing.T, entries []*tar.Header) []byte {
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	for _, hdr := range entries {
+		assert.Nil(t, w.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg && hdr.Size > 0 {
+			_, err := w.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+			assert.Nil(t, err)
+		}
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestSecureExtractRejectsPathTraversal(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-secure-traversal")
+	os.RemoveAll(dest)
+
+	data := buildMaliciousTar(t, []*tar.Header{
+		{Name: "../escaped.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+	})
+	archive := &Tar{Data: data}
+	err := archive.SecureExtract(dest)
+	assert.Equal(t, breakoutError, err)
+}
+
+func TestSecureExtractRejectsAbsolutePath(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-secure-absolute")
+	os.RemoveAll(dest)
+
+	data := buildMaliciousTar(t, []*tar.Header{
+		{Name: "/etc/escaped.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+	})
+	archive := &Tar{Data: data}
+	err := archive.SecureExtract(dest)
+	assert.Equal(t, breakoutError, err)
+}
+
+func TestSecureExtractRejectsSymlinkEscape(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-secure-symlink-escape")
+	os.RemoveAll(dest)
+
+	data := buildMaliciousTar(t, []*tar.Header{
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../../etc", Mode: 0777},
+	})
+	archive := &Tar{Data: data}
+	err := archive.SecureExtract(dest)
+	assert.Equal(t, breakoutError, err)
+}
+
+func TestSecureExtractRejectsSymlinkThenWrite(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-secure-symlink-then-write")
+	os.RemoveAll(dest)
+
+	// "evil" is a legitimately-scoped symlink (it resolves to the
+	// extraction root itself), but a later entry must not be allowed to
+	// write *through* it as if it were a plain directory
+	data := buildMaliciousTar(t, []*tar.Header{
+		{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: ".", Mode: 0777},
+		{Name: "evil/payload.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+	})
+	archive := &Tar{Data: data}
+	err := archive.SecureExtract(dest)
+	assert.Equal(t, breakoutError, err)
+	_, statErr := os.Lstat(filepath.Join(dest, "evil", "payload.txt"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestSecureExtractAllowsWellBehavedArchive(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-secure-ok")
+	os.RemoveAll(dest)
+
+	data := buildMaliciousTar(t, []*tar.Header{
+		{Name: "sub", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "sub/file.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+	})
+	archive := &Tar{Data: data}
+	err := archive.SecureExtract(dest)
+	assert.Nil(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(dest, "sub", "file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "xxxx", string(content))
+}
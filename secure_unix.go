@@ -0,0 +1,10 @@
+//go:build !windows
+
+package tatar
+
+import "syscall"
+
+// noFollowFlag is OR'ed into the flags used to open path components while
+// walking towards an extraction target, so a pre-existing symlink in the
+// way is refused instead of silently followed
+const noFollowFlag = syscall.O_NOFOLLOW
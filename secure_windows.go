@@ -0,0 +1,7 @@
+//go:build windows
+
+package tatar
+
+// noFollowFlag is a no-op on windows, which has no O_NOFOLLOW; the
+// filepath-prefix check in secureJoin is still enforced
+const noFollowFlag = 0
@@ -0,0 +1,270 @@
+package tatar
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StreamWriter writes tar entries directly to a compressed writer without
+// buffering the whole archive in memory. Use NewStreamWriter to create one
+type StreamWriter struct {
+	compressCloser io.Closer
+	tarWriter      *tar.Writer
+}
+
+// NewStreamWriter creates a StreamWriter which compresses its tar stream
+// with c and writes it to w
+func NewStreamWriter(w io.Writer, c CompressionType) (*StreamWriter, error) {
+	compressedWriter, closer, err := newCompressor(w, c, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamWriter{
+		compressCloser: closer,
+		tarWriter:      tar.NewWriter(compressedWriter),
+	}, nil
+}
+
+// AddFile writes a single tar entry described by hdr, copying r as its
+// content. r may be nil for entries without content (directories, symlinks)
+func (sw *StreamWriter) AddFile(hdr *tar.Header, r io.Reader) error {
+	if err := sw.tarWriter.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if r == nil {
+		return nil
+	}
+	_, err := io.Copy(sw.tarWriter, r)
+	return err
+}
+
+// AddPath adds the file, directory or symlink at fsPath to the archive
+// under archivePath
+func (sw *StreamWriter) AddPath(fsPath, archivePath string) error {
+	info, err := os.Lstat(fsPath)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(fsPath)
+		if err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = archivePath
+	if info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+		return sw.AddFile(hdr, nil)
+	}
+	f, err := os.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sw.AddFile(hdr, f)
+}
+
+// AddDirectory walks dir and adds its contents (!) to the archive, the same
+// way NewFromDirectory does
+func (sw *StreamWriter) AddDirectory(dir string) error {
+	dir, _ = filepath.Abs(dir)
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Clean(dir) == filepath.Clean(path) {
+			return nil
+		}
+		return sw.AddPath(path, path[len(dir)+1:])
+	})
+}
+
+// Close flushes the tar trailer and closes the underlying compressor.
+// It does not close the writer passed to NewStreamWriter
+func (sw *StreamWriter) Close() error {
+	if err := sw.tarWriter.Close(); err != nil {
+		return err
+	}
+	if sw.compressCloser != nil {
+		return sw.compressCloser.Close()
+	}
+	return nil
+}
+
+// StreamReader iterates over a compressed tar stream without buffering the
+// whole archive in memory. Use NewStreamReader to create one
+type StreamReader struct {
+	decompressCloser io.Closer
+	tarReader        *tar.Reader
+}
+
+// NewStreamReader creates a StreamReader over r using the given compression.
+// If c is NO_COMPRESSION or AUTO, the stream is sniffed for a known magic
+// byte header the same way Load does
+func NewStreamReader(r io.Reader, c CompressionType) (*StreamReader, error) {
+	if c == NO_COMPRESSION || c == AUTO {
+		br := bufio.NewReader(r)
+		detected, err := sniffCompression(br)
+		if err != nil {
+			return nil, err
+		}
+		c = detected
+		r = br
+	}
+	decompressedReader, closer, err := newDecompressor(r, c)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamReader{
+		decompressCloser: closer,
+		tarReader:        tar.NewReader(decompressedReader),
+	}, nil
+}
+
+// Next advances to the next entry in the archive and returns its header
+// together with a reader for its content
+func (sr *StreamReader) Next() (*tar.Header, io.Reader, error) {
+	hdr, err := sr.tarReader.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	return hdr, sr.tarReader, nil
+}
+
+// Close closes the underlying decompressor, if any. It does not close the
+// reader passed to NewStreamReader
+func (sr *StreamReader) Close() error {
+	if sr.decompressCloser != nil {
+		return sr.decompressCloser.Close()
+	}
+	return nil
+}
+
+// ExtractTo extracts every entry read from the stream into dir, creating it
+// if necessary
+func (sr *StreamReader) ExtractTo(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for {
+		hdr, r, err := sr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := extractEntry(dir, hdr, r); err != nil {
+			return err
+		}
+	}
+}
+
+// breakoutError is returned whenever an entry's name or linkname would place
+// a file outside of the extraction root
+var breakoutError = errors.New("tatar: refusing to extract entry outside of destination directory")
+
+// isWithinRoot reports whether target is root itself or a descendant of it.
+// Both paths must already be absolute and clean
+func isWithinRoot(root, target string) bool {
+	if target == root {
+		return true
+	}
+	return strings.HasPrefix(target, root+string(filepath.Separator))
+}
+
+// secureJoin resolves name against root, rejecting absolute names and any
+// name whose resolved path escapes root. It also walks the existing parent
+// directories of the result and refuses to proceed if one of them is a
+// symlink, guarding against a symlink planted by an earlier entry in the
+// same archive
+func secureJoin(root, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", breakoutError
+	}
+	target := filepath.Clean(filepath.Join(root, name))
+	if !isWithinRoot(root, target) {
+		return "", breakoutError
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	current := root
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		fi, err := os.Lstat(current)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", breakoutError
+		}
+		f, err := os.OpenFile(current, os.O_RDONLY|noFollowFlag, 0)
+		if err != nil {
+			return "", breakoutError
+		}
+		f.Close()
+	}
+	return target, nil
+}
+
+// validateLinkname rejects an absolute linkname or one that, resolved
+// relative to the directory of entryPath, escapes root
+func validateLinkname(root, entryPath, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return breakoutError
+	}
+	resolved := filepath.Clean(filepath.Join(filepath.Dir(entryPath), linkname))
+	if !isWithinRoot(root, resolved) {
+		return breakoutError
+	}
+	return nil
+}
+
+// extractEntry writes a single tar entry below root, matching the behaviour
+// previously inlined in Tar.ToDirectory, but rejects path-traversal and
+// symlink-escape attempts
+func extractEntry(root string, hdr *tar.Header, r io.Reader) error {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	targetPath, err := secureJoin(rootAbs, hdr.Name)
+	if err != nil {
+		return err
+	}
+	if hdr.Linkname != "" {
+		if err := validateLinkname(rootAbs, targetPath, hdr.Linkname); err != nil {
+			return err
+		}
+	}
+	if hdr.FileInfo().IsDir() {
+		return os.MkdirAll(targetPath, os.FileMode(hdr.Mode))
+	}
+	if hdr.FileInfo().Mode()&os.ModeSymlink != 0 {
+		return os.Symlink(hdr.Linkname, targetPath)
+	}
+	f, err := os.Create(targetPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	return f.Chmod(os.FileMode(hdr.Mode))
+}
@@ -0,0 +1,52 @@
+package tatar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWriterAddDirectory(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer, err := NewStreamWriter(buf, GZIP)
+	assert.Nil(t, err)
+	err = writer.AddDirectory(testDir)
+	assert.Nil(t, err)
+	err = writer.Close()
+	assert.Nil(t, err)
+
+	reader, err := NewStreamReader(bytes.NewReader(buf.Bytes()), GZIP)
+	assert.Nil(t, err)
+
+	hdr, r, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "data1.txt", hdr.Name)
+	content, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, data1, content)
+}
+
+func TestStreamReaderExtractTo(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer, err := NewStreamWriter(buf, NO_COMPRESSION)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.AddDirectory(testDir))
+	assert.Nil(t, writer.Close())
+
+	extractDir := filepath.Join(os.TempDir(), "tatar-test-stream-target")
+	os.RemoveAll(extractDir)
+
+	reader, err := NewStreamReader(bytes.NewReader(buf.Bytes()), AUTO)
+	assert.Nil(t, err)
+	err = reader.ExtractTo(extractDir)
+	assert.Nil(t, err)
+
+	cmd := exec.Command("diff", "-r", testDir, extractDir)
+	err = cmd.Run()
+	assert.Nil(t, err)
+}
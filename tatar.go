@@ -2,15 +2,12 @@ package tatar
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
-	"compress/gzip"
-	"errors"
 	"io"
 	"os"
 	"path/filepath"
-
-	"github.com/dsnet/compress/bzip2"
-	"github.com/ulikunitz/xz"
+	"strings"
 )
 
 // Tar contains the uncompressed tar data and the desired Compression
@@ -18,10 +15,13 @@ import (
 type Tar struct {
 	Data        []byte
 	Compression CompressionType
+	// CompressionLevel is passed to the registered Codec if it implements
+	// LeveledCodec. Zero means "use the codec's default level"
+	CompressionLevel int
 }
 
 // CompressionType specifies the compression.
-// Valid values: NO_COMPRESSION, GZIP, BZIP2, LZMA
+// Valid values: NO_COMPRESSION, GZIP, BZIP2, LZMA, ZSTD, LZ4, AUTO
 type CompressionType int
 
 const (
@@ -29,58 +29,27 @@ const (
 	GZIP
 	BZIP2
 	LZMA
+	ZSTD
+	LZ4
+	// AUTO makes Load sniff the stream for a known magic byte header instead
+	// of trusting the configured Compression
+	AUTO
 )
 
 // NewFromDirectory creates a tar archive from the contents (!) of the given directory
 func NewFromDirectory(directory string) (*Tar, error) {
-	directory, _ = filepath.Abs(directory)
-	res := &Tar{}
 	buf := &bytes.Buffer{}
-	writer := tar.NewWriter(buf)
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if filepath.Clean(directory) == filepath.Clean(path) {
-			return nil
-		}
-		link := ""
-		if info.Mode()&os.ModeSymlink != 0 {
-			l, err := os.Readlink(path)
-			if err != nil {
-				return err
-			}
-			link = l
-		}
-		hdr, err := tar.FileInfoHeader(info, link)
-		if err != nil {
-			return err
-		}
-		hdr.Name = path[len(directory)+1:]
-		err = writer.WriteHeader(hdr)
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
-			f, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			_, err = io.Copy(writer, f)
-			return err
-		}
-		return nil
-	})
+	writer, err := NewStreamWriter(buf, NO_COMPRESSION)
 	if err != nil {
 		return nil, err
 	}
-	err = writer.Close()
-	if err != nil {
+	if err := writer.AddDirectory(directory); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
 		return nil, err
 	}
-	res.Data = buf.Bytes()
-	return res, nil
+	return &Tar{Data: buf.Bytes()}, nil
 }
 
 // NewFromData loades a datablob with the specified compression
@@ -91,16 +60,34 @@ func NewFromData(data []byte, compression CompressionType) (*Tar, error) {
 	return result, err
 }
 
-// NewFromFile loades a tar from a file.
-// CompressionType is guessed by fileextension
-func NewFromFile(path string) (*Tar, error) {
-	t := &Tar{Compression: GuessCompression(path)}
+// NewFromFile loads an archive from a file, dispatching to NewZipFromDirectory's
+// sibling Zip type when the file is a zip (by magic bytes or ".zip"
+// extension) and to Tar otherwise. CompressionType of the tar case is
+// guessed by fileextension
+func NewFromFile(path string) (Archive, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	_, err = t.Load(f)
+	br := bufio.NewReader(f)
+	head, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".zip") || bytes.HasPrefix(head, zipMagic) {
+		return newZipFromReader(br)
+	}
+	t := &Tar{Compression: GuessCompression(path)}
+	_, err = t.Load(br)
+	return t, err
+}
+
+// NewFromReader loads a tar from an arbitrary io.Reader, auto-detecting its
+// compression from the stream's magic bytes
+func NewFromReader(r io.Reader) (*Tar, error) {
+	t := &Tar{Compression: AUTO}
+	_, err := t.Load(r)
 	return t, err
 }
 
@@ -124,74 +111,32 @@ func (t *Tar) ToFile(path string) (int64, error) {
 	return t.Save(f)
 }
 
-// ToDirectory extracts the tars contents into the given directory
+// ToDirectory extracts the tars contents into the given directory.
+// Every entry is validated against path-traversal and symlink-escape
+// attempts before being written, see SecureExtract
 func (t *Tar) ToDirectory(path string) error {
-	err := os.MkdirAll(path, 0755)
+	reader, err := NewStreamReader(bytes.NewReader(t.Data), NO_COMPRESSION)
 	if err != nil {
 		return err
 	}
-	return t.ForEach(func(hdr *tar.Header, reader io.Reader) error {
-		if hdr.FileInfo().IsDir() {
-			err := os.MkdirAll(filepath.Join(path, hdr.Name), os.FileMode(hdr.Mode))
-			if err != nil {
-				return err
-			}
-		} else if (hdr.FileInfo().Mode() & os.ModeSymlink) != 0 {
-			os.Symlink(hdr.Linkname, hdr.Name)
-		} else {
-			targetPath := filepath.Join(path, hdr.Name)
-			f, e := os.Create(targetPath)
-			if e != nil {
-				return e
-			}
-			if _, e = io.Copy(f, reader); e != nil {
-				f.Close()
-				return e
-			}
-			e = f.Chmod(os.FileMode(hdr.Mode))
-			if e != nil {
-				f.Close()
-				return e
-			}
-			f.Close()
-			return nil
-		}
-		return nil
-	})
+	return reader.ExtractTo(path)
+}
+
+// SecureExtract extracts the tar contents into path, same as ToDirectory.
+// It is provided as an explicit name for call sites that want the
+// path-traversal and symlink-escape protection to be obvious in the code
+func (t *Tar) SecureExtract(path string) error {
+	return t.ToDirectory(path)
 }
 
 // Save compresses the tar into the specified writer
 func (t *Tar) Save(out io.Writer) (int64, error) {
-	var compressedWriter io.Writer
-	switch t.Compression {
-	case NO_COMPRESSION:
-		compressedWriter = out
-	case GZIP:
-		{
-			gzipWriter := gzip.NewWriter(out)
-			defer gzipWriter.Close()
-			compressedWriter = gzipWriter
-		}
-	case BZIP2:
-		{
-			bzip2Writer, err := bzip2.NewWriter(out, nil)
-			if err != nil {
-				return 0, err
-			}
-			defer bzip2Writer.Close()
-			compressedWriter = bzip2Writer
-		}
-	case LZMA:
-		{
-			w, err := xz.NewWriter(out)
-			if err != nil {
-				return 0, err
-			}
-			defer w.Close()
-			compressedWriter = w
-		}
-	default:
-		return 0, errors.New("unknown compression")
+	compressedWriter, closer, err := newCompressor(out, t.Compression, t.CompressionLevel)
+	if err != nil {
+		return 0, err
+	}
+	if closer != nil {
+		defer closer.Close()
 	}
 	res, err := compressedWriter.Write(t.Data)
 	if err != nil {
@@ -200,38 +145,22 @@ func (t *Tar) Save(out io.Writer) (int64, error) {
 	return int64(res), nil
 }
 
-// Load decompresses the tar from the specified reader
+// Load decompresses the tar from the specified reader.
+// If Compression is NO_COMPRESSION or AUTO, the stream is peeked for a
+// known magic byte header and t.Compression is set to the detected value
 func (t *Tar) Load(in io.Reader) (int64, error) {
-	var compressedReader io.Reader
-	switch t.Compression {
-	case NO_COMPRESSION:
-		{
-			compressedReader = in
-		}
-	case GZIP:
-		{
-			gzipReader, err := gzip.NewReader(in)
-			if err != nil {
-				return 0, err
-			}
-			compressedReader = gzipReader
-		}
-	case BZIP2:
-		{
-			bzip2Reader, err := bzip2.NewReader(in, nil)
-			if err != nil {
-				return 0, err
-			}
-			compressedReader = bzip2Reader
-		}
-	case LZMA:
-		{
-			r, err := xz.NewReader(in)
-			if err != nil {
-				return 0, err
-			}
-			compressedReader = r
+	if t.Compression == NO_COMPRESSION || t.Compression == AUTO {
+		br := bufio.NewReader(in)
+		detected, err := sniffCompression(br)
+		if err != nil {
+			return 0, err
 		}
+		t.Compression = detected
+		in = br
+	}
+	compressedReader, _, err := newDecompressor(in, t.Compression)
+	if err != nil {
+		return 0, err
 	}
 	buf := &bytes.Buffer{}
 	bs, err := io.Copy(buf, compressedReader)
@@ -263,17 +192,3 @@ func (t *Tar) ForEach(cb func(header *tar.Header, reader io.Reader) error) error
 	}
 	return nil
 }
-
-// GuessCompression guesses the compression type by fileextension
-func GuessCompression(name string) CompressionType {
-	ext := filepath.Ext(name)
-	switch ext {
-	case ".xz", ".XZ", ".lzma", ".LZMA":
-		return LZMA
-	case ".bz2", ".BZ2", ".bzip2", ".BZIP2":
-		return BZIP2
-	case ".gz", ".GZ", ".gzip", ".GZIP":
-		return GZIP
-	}
-	return NO_COMPRESSION
-}
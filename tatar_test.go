@@ -157,6 +157,29 @@ func TestToData(t *testing.T) {
 	assert.True(t, len(data) > 0)
 }
 
+func TestNewFromReaderAutoDetect(t *testing.T) {
+	archive, err := NewFromDirectory(testDir)
+	assert.Nil(t, err)
+
+	archive.Compression = GZIP
+	gzData, err := archive.ToData()
+	assert.Nil(t, err)
+
+	restoredArchive, err := NewFromReader(bytes.NewReader(gzData))
+	assert.Nil(t, err)
+	assert.Equal(t, GZIP, restoredArchive.Compression)
+	assert.Equal(t, archive.Data, restoredArchive.Data)
+
+	archive.Compression = NO_COMPRESSION
+	rawData, err := archive.ToData()
+	assert.Nil(t, err)
+
+	restoredArchive, err = NewFromData(rawData, AUTO)
+	assert.Nil(t, err)
+	assert.Equal(t, NO_COMPRESSION, restoredArchive.Compression)
+	assert.Equal(t, archive.Data, restoredArchive.Data)
+}
+
 func TestFromData(t *testing.T) {
 
 	archive, err := NewFromDirectory(testDir)
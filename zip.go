@@ -0,0 +1,267 @@
+package tatar
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// zipMagic is the PK\x03\x04 local file header signature every zip file
+// starts with
+var zipMagic = []byte{0x50, 0x4B, 0x03, 0x04}
+
+// zipCreatorUnix marks a zip entry's ExternalAttrs as holding a unix mode in
+// its upper 16 bits, the same convention used by Info-ZIP and the stdlib
+const zipCreatorUnix = 3
+
+// Zip contains the raw bytes of a zip archive. It mirrors the Tar API so
+// callers can transparently repack between the two formats
+type Zip struct {
+	Data []byte
+}
+
+// NewZipFromDirectory creates a zip archive from the contents (!) of the
+// given directory, the same way NewFromDirectory does for tar
+func NewZipFromDirectory(directory string) (*Zip, error) {
+	directory, _ = filepath.Abs(directory)
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Clean(directory) == filepath.Clean(path) {
+			return nil
+		}
+		return addToZipWriter(zw, path, path[len(directory)+1:], info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &Zip{Data: buf.Bytes()}, nil
+}
+
+func setUnixMode(hdr *zip.FileHeader, mode os.FileMode) {
+	var sysMode uint32
+	switch {
+	case mode&os.ModeSymlink != 0:
+		sysMode = 0xA000 // S_IFLNK
+	case mode.IsDir():
+		sysMode = 0x4000 // S_IFDIR
+	default:
+		sysMode = 0x8000 // S_IFREG
+	}
+	sysMode |= uint32(mode.Perm())
+	hdr.ExternalAttrs = sysMode << 16
+	hdr.CreatorVersion = zipCreatorUnix<<8 | 20
+}
+
+// unixModeOf decodes the unix permissions and file type stored in
+// ExternalAttrs of entries whose creator is unix (as written by tatar or
+// Info-ZIP), falling back to f.Mode() for entries from any other creator
+func unixModeOf(f *zip.File) os.FileMode {
+	if f.CreatorVersion>>8 != zipCreatorUnix {
+		return f.Mode()
+	}
+	sysMode := f.ExternalAttrs >> 16
+	perm := os.FileMode(sysMode & 0777)
+	switch sysMode & 0xF000 {
+	case 0xA000:
+		return perm | os.ModeSymlink
+	case 0x4000:
+		return perm | os.ModeDir
+	default:
+		return perm
+	}
+}
+
+// addToZipWriter writes fsPath into zw under archivePath, preserving unix
+// permissions and representing symlinks as a ModeSymlink entry whose
+// content is the link target, stored rather than deflated
+func addToZipWriter(zw *zip.Writer, fsPath, archivePath string, info os.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fsPath)
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = archivePath
+		hdr.Method = zip.Store
+		setUnixMode(hdr, info.Mode())
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(target))
+		return err
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = archivePath
+	if info.IsDir() {
+		hdr.Name += "/"
+		hdr.Method = zip.Store
+	} else {
+		hdr.Method = zip.Deflate
+	}
+	setUnixMode(hdr, info.Mode())
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	f, err := os.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// ToDirectory extracts the zip's contents into the given directory, with
+// the same path-traversal and symlink-escape protection as Tar.ToDirectory
+func (z *Zip) ToDirectory(path string) error {
+	r, err := zip.NewReader(bytes.NewReader(z.Data), int64(len(z.Data)))
+	if err != nil {
+		return err
+	}
+	rootAbs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootAbs, 0755); err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if err := extractZipEntry(rootAbs, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipEntry(root string, f *zip.File) error {
+	targetPath, err := secureJoin(root, f.Name)
+	if err != nil {
+		return err
+	}
+	mode := unixModeOf(f)
+	if mode.IsDir() {
+		return os.MkdirAll(targetPath, mode.Perm())
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if mode&os.ModeSymlink != 0 {
+		linknameBytes, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		linkname := string(linknameBytes)
+		if err := validateLinkname(root, targetPath, linkname); err != nil {
+			return err
+		}
+		return os.Symlink(linkname, targetPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(targetPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, rc); err != nil {
+		return err
+	}
+	return out.Chmod(mode.Perm())
+}
+
+// ToFile saves the zip to a file
+func (z *Zip) ToFile(path string) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := f.Write(z.Data)
+	return int64(n), err
+}
+
+// ForEach iterates over the zip's entries, and calls the given callback for
+// each one together with a reader for its content
+func (z *Zip) ForEach(cb func(hdr *zip.FileHeader, r io.Reader) error) error {
+	r, err := zip.NewReader(bytes.NewReader(z.Data), int64(len(z.Data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = cb(&f.FileHeader, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add appends the file, directory or symlink at fsPath to the archive under
+// archivePath, rebuilding Data in place. Existing entries are copied
+// without being recompressed
+func (z *Zip) Add(fsPath, archivePath string) error {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	if len(z.Data) > 0 {
+		r, err := zip.NewReader(bytes.NewReader(z.Data), int64(len(z.Data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range r.File {
+			if err := zw.Copy(f); err != nil {
+				return err
+			}
+		}
+	}
+	info, err := os.Lstat(fsPath)
+	if err != nil {
+		return err
+	}
+	if err := addToZipWriter(zw, fsPath, archivePath, info); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	z.Data = buf.Bytes()
+	return nil
+}
+
+// newZipFromReader buffers r fully into a Zip, mirroring how Tar.Load keeps
+// its Data in memory
+func newZipFromReader(r io.Reader) (*Zip, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Zip{Data: data}, nil
+}
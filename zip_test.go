@@ -0,0 +1,100 @@
+package tatar
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewZipFromDirectoryRoundTrip(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-zip-roundtrip")
+	os.RemoveAll(dest)
+
+	archive, err := NewZipFromDirectory(testDir)
+	assert.Nil(t, err)
+	err = archive.ToDirectory(dest)
+	assert.Nil(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "data1.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, data1, content)
+	content, err = ioutil.ReadFile(filepath.Join(dest, "sub", "data2.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, data2, content)
+}
+
+func TestZipToFileAndNewFromFile(t *testing.T) {
+	testFileZip := filepath.Join(os.TempDir(), "tatar-test.zip")
+	dest := filepath.Join(os.TempDir(), "tatar-test-zip-fromfile")
+	os.RemoveAll(dest)
+
+	archive, err := NewZipFromDirectory(testDir)
+	assert.Nil(t, err)
+	_, err = archive.ToFile(testFileZip)
+	assert.Nil(t, err)
+
+	restored, err := NewFromFile(testFileZip)
+	assert.Nil(t, err)
+	assert.IsType(t, &Zip{}, restored)
+	err = restored.ToDirectory(dest)
+	assert.Nil(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(dest, "data1.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, data1, content)
+}
+
+func TestZipAdd(t *testing.T) {
+	archive := &Zip{}
+	err := archive.Add(filepath.Join(testDir, "data1.txt"), "added/data1.txt")
+	assert.Nil(t, err)
+
+	var found bool
+	err = archive.ForEach(func(hdr *zip.FileHeader, r io.Reader) error {
+		if hdr.Name == "added/data1.txt" {
+			found = true
+			content, err := ioutil.ReadAll(r)
+			assert.Nil(t, err)
+			assert.Equal(t, data1, content)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.True(t, found)
+}
+
+func TestZipToDirectoryRejectsPathTraversal(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-zip-traversal")
+	os.RemoveAll(dest)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("../escaped.txt")
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("x"))
+	assert.Nil(t, err)
+	assert.Nil(t, zw.Close())
+
+	archive := &Zip{Data: buf.Bytes()}
+	err = archive.ToDirectory(dest)
+	assert.Equal(t, breakoutError, err)
+}
+
+func TestZipPreservesUnixMode(t *testing.T) {
+	dest := filepath.Join(os.TempDir(), "tatar-test-zip-mode")
+	os.RemoveAll(dest)
+
+	archive, err := NewZipFromDirectory(testDir)
+	assert.Nil(t, err)
+	err = archive.ToDirectory(dest)
+	assert.Nil(t, err)
+
+	info, err := os.Stat(filepath.Join(dest, "data1.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}